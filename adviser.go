@@ -3,16 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/hashicorp/golang-lru"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -22,67 +28,115 @@ const (
 	request         = "request"
 	response        = "response"
 	urlStr          = "url"
+
+	ageHeader   = "Age"
+	cacheHeader = "X-Cache"
+
+	cacheHit   = "HIT"
+	cacheStale = "STALE"
+	cacheMiss  = "MISS"
 )
 
 // internalErr is a standard error.
 var internalErr = []byte(`{"error": "internal error"}`)
 
-// inputResp is a target service response structure.
-type inputResp []*inputItem
-
-// inputResp is a response that a client expects.
-type outputResp []*outputItem
-
-// outputItem is a input item.
-type inputItem struct {
-	IndexStrings    []string           `json:"index_strings"`
-	CountryCode     string             `json:"country_code"`
-	StateCode       interface{}        `json:"state_code"` // unknown type
-	Cases           map[string]string  `json:"cases"`
-	Coordinates     map[string]float64 `json:"coordinates"`
-	CountryCases    interface{}        `json:"country_cases"` // unknown type
-	Code            string             `json:"code"`
-	Name            string             `json:"name"`
-	Weight          int64              `json:"weight"`
-	Type            string             `json:"type"`
-	CountryName     string             `json:"country_name"`
-	MainAirportName interface{}        `json:"main_airport_name"` // unknown type
+// inputResp is a target service response structure: each item is kept
+// as a generic map so a route's field_map can pull arbitrary, possibly
+// nested, fields out of it.
+type inputResp []map[string]interface{}
+
+// outputResp is a response that a client expects: each item is built
+// from the matched route's field_map.
+type outputResp []map[string]interface{}
+
+// cacheEntry is a cached response together with the bookkeeping needed
+// to serve it fresh, stale-while-revalidate, or not at all. Fields are
+// exported so Cache implementations can JSON-encode it.
+type cacheEntry struct {
+	Data      outputResp    `json:"data"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	TTL       time.Duration `json:"ttl"`
 }
 
-// outputItem is a output item.
-type outputItem struct {
-	Slug     string `json:"slug"`
-	Subtitle string `json:"subtitle"`
-	Title    string `json:"title"`
+// age reports how long ago the entry was fetched.
+func (e *cacheEntry) age() time.Duration {
+	return time.Since(e.FetchedAt)
 }
 
 // server is a http server.
 type server struct {
-	debug         bool
-	cache         *lru.Cache
-	httpServer    *http.Server
-	logger        *zap.SugaredLogger
-	reqTimeout    time.Duration
-	targetAddress string
+	mu sync.RWMutex // Guards the reloadable fields below.
+
+	debug          bool
+	cache          Cache
+	httpServer     *http.Server
+	httpClient     *http.Client
+	logger         *zap.SugaredLogger
+	reqTimeout     time.Duration
+	routes         []*route
+	cacheTTL       time.Duration
+	cacheStaleTTL  time.Duration
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	// group coalesces concurrent upstream fetches for the same key so
+	// that a cache miss only triggers a single request upstream.
+	group singleflight.Group
+
+	// adminServer exposes /metrics on a separate address from the
+	// proxy listener; nil if config.AdminAddr is empty.
+	adminServer *http.Server
+	metrics     *metrics
+	tracer      trace.Tracer
 }
 
 // config is an application configuration.
 type config struct {
-	Addr           string
-	CacheSize      int
-	LogLevel       string
-	RequestTimeout uint64 // In milliseconds.
-	TargetAddr     string
+	Addr                string
+	AdminAddr           string // Serves /metrics; disabled if empty.
+	CacheBackend        string // "lru" (default) or "redis".
+	CacheSize           int
+	CacheTTL            uint64 // Fresh window, in milliseconds.
+	CacheStaleTTL       uint64 // Stale-while-revalidate window, in milliseconds.
+	LogLevel            string
+	MaxIdleConnsPerHost int
+	MaxRetries          int
+	RedisURL            string // Used when CacheBackend is "redis".
+	RequestTimeout      uint64 // In milliseconds.
+	RetryBaseDelay      uint64 // Initial backoff between retries, in milliseconds.
+	Routes              []*route
+	TracingEnabled      bool
+	TracingEndpoint     string // OTLP/gRPC collector address.
 }
 
 // newConfig creates a new default config.
 func newConfig() *config {
 	return &config{
-		Addr:           ":80",
-		CacheSize:      1000,
-		LogLevel:       "info",
-		RequestTimeout: 3000,
-		TargetAddr:     "https://places.aviasales.ru",
+		Addr:                ":80",
+		AdminAddr:           ":9090",
+		CacheBackend:        cacheBackendLRU,
+		CacheSize:           1000,
+		CacheTTL:            60000,
+		CacheStaleTTL:       300000,
+		LogLevel:            "info",
+		MaxIdleConnsPerHost: 10,
+		MaxRetries:          3,
+		RedisURL:            "redis://localhost:6379/0",
+		RequestTimeout:      3000,
+		RetryBaseDelay:      100,
+		Routes: []*route{
+			{
+				PathPrefix: "/",
+				TargetAddr: "https://places.aviasales.ru",
+				FieldMap: map[string]string{
+					"slug":     "code",
+					"subtitle": "country_name",
+					"title":    "name",
+				},
+			},
+		},
+		TracingEnabled:  false,
+		TracingEndpoint: "localhost:4317",
 	}
 }
 
@@ -99,70 +153,129 @@ func readConfig(name string, data interface{}) error {
 	return json.NewDecoder(file).Decode(data)
 }
 
+// request fetches url from targetAddr and decodes the JSON body into
+// result, retrying transient failures with an exponential backoff. ctx
+// carries a single deadline shared across every attempt, so retries
+// never push the total wall-clock past the caller's timeout.
 func (s *server) request(ctx context.Context, logger *zap.SugaredLogger,
-	url string, timeout time.Duration, result interface{}) error {
+	targetAddr, url string, result interface{}) error {
 
 	// With target hostname.
-	url = s.targetAddress + url
+	url = targetAddr + url
+
+	client, maxRetries, baseDelay := s.retryPolicy()
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		body, status, err := s.do(ctx, logger, client, url)
+		if err == nil && status == http.StatusOK {
+			if err := json.Unmarshal(body, &result); err != nil {
+				logger.Error(err)
+				s.metrics.observeUpstreamError(false, reasonDecode)
+				return err
+			}
+
+			return nil
+		}
+
+		if err == nil {
+			err = errors.New(http.StatusText(status))
+		}
+		lastErr = err
+
+		retryable := isRetryableErr(err) || isRetryableStatus(status)
+		if attempt >= maxRetries || !retryable {
+			logger.Error(lastErr)
+			s.metrics.observeUpstreamError(
+				ctx.Err() == context.DeadlineExceeded, errReason(status))
+			return lastErr
+		}
+
+		select {
+		case <-time.After(backoff(baseDelay, attempt)):
+		case <-ctx.Done():
+			logger.Error(lastErr)
+			s.metrics.observeUpstreamError(true, errReason(status))
+			return lastErr
+		}
+	}
+}
+
+// do performs a single attempt of the upstream request, returning the
+// response body and status on success.
+func (s *server) do(ctx context.Context, logger *zap.SugaredLogger,
+	client *http.Client, url string) ([]byte, int, error) {
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		logger.Error(err)
-		return err
+		return nil, 0, err
 	}
 
 	req = req.WithContext(ctx)
-	client := &http.Client{}
 
+	start := time.Now()
 	res, err := client.Do(req)
+	s.metrics.upstreamLatency.Observe(time.Since(start).Seconds())
 	if err != nil {
-		logger.Error(err)
-		return err
-	}
-
-	if res.StatusCode != http.StatusOK {
-		err = fmt.Errorf(http.StatusText(res.StatusCode))
-		logger.Error(err)
-		return err
+		return nil, 0, err
 	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		logger.Error(err)
-		return err
+		return nil, res.StatusCode, err
 	}
 
-	defer func() {
-		_ = res.Body.Close()
-	}()
+	return body, res.StatusCode, nil
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		logger.Error(err)
-		return err
+// errReason classifies a failed attempt for the upstream error metric:
+// status is non-zero whenever the target responded at all, even with a
+// body-read failure or a non-200 status; anything else is a transport
+// failure.
+func errReason(status int) string {
+	if status != 0 {
+		return reasonStatus
 	}
 
-	return nil
+	return reasonNetwork
 }
 
 func newServer(
-	logger *zap.SugaredLogger, cache *lru.Cache, cfg *config) *server {
+	logger *zap.SugaredLogger, cache Cache, cfg *config) *server {
 	var debug bool
 
 	if cfg.LogLevel == "debug" || cfg.LogLevel == "DEBUG" {
 		debug = true
 	}
 
+	m, reg := newMetrics()
+
 	srv := &server{
-		debug:         debug,
-		cache:         cache,
-		httpServer:    &http.Server{Addr: cfg.Addr},
-		logger:        logger,
-		reqTimeout:    time.Duration(cfg.RequestTimeout) * time.Millisecond,
-		targetAddress: cfg.TargetAddr,
+		debug:          debug,
+		cache:          cache,
+		httpServer:     &http.Server{Addr: cfg.Addr},
+		httpClient:     newHTTPClient(cfg),
+		logger:         logger,
+		reqTimeout:     time.Duration(cfg.RequestTimeout) * time.Millisecond,
+		routes:         cfg.Routes,
+		cacheTTL:       time.Duration(cfg.CacheTTL) * time.Millisecond,
+		cacheStaleTTL:  time.Duration(cfg.CacheStaleTTL) * time.Millisecond,
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: time.Duration(cfg.RetryBaseDelay) * time.Millisecond,
+		metrics:        m,
+		tracer:         tracer(),
 	}
 
 	srv.httpServer.Handler = http.HandlerFunc(srv.handlerFunc)
 
+	if cfg.AdminAddr != "" {
+		srv.adminServer = adminServer(cfg.AdminAddr, reg)
+	}
+
 	return srv
 }
 
@@ -170,44 +283,189 @@ func (s *server) listenAndServe() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// listenAndServeAdmin serves /metrics on the admin address. It returns
+// immediately with no error if no admin address is configured.
+func (s *server) listenAndServeAdmin() error {
+	if s.adminServer == nil {
+		return nil
+	}
+
+	return s.adminServer.ListenAndServe()
+}
+
+// Shutdown gracefully stops the server: it stops accepting new
+// connections and waits for in-flight requests to finish, or for ctx
+// to be done, whichever comes first.
+func (s *server) Shutdown(ctx context.Context) error {
+	if s.adminServer != nil {
+		_ = s.adminServer.Shutdown(ctx)
+	}
+
+	return s.httpServer.Shutdown(ctx)
+}
+
+// reload re-reads the configuration file and rebuilds the cache and
+// logger in place, without dropping the listening socket.
+func (s *server) reload(cfgPath string) error {
+	cfg := newConfig()
+	if err := readConfig(cfgPath, cfg); err != nil {
+		return err
+	}
+
+	cache, err := newCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	logger := newLogger(cfg.LogLevel).With("config", cfg)
+
+	debug := cfg.LogLevel == "debug" || cfg.LogLevel == "DEBUG"
+
+	s.mu.Lock()
+
+	oldCache := s.cache
+
+	s.cache = cache
+	s.logger = logger
+	s.debug = debug
+	s.httpClient = newHTTPClient(cfg)
+	s.reqTimeout = time.Duration(cfg.RequestTimeout) * time.Millisecond
+	s.routes = cfg.Routes
+	s.cacheTTL = time.Duration(cfg.CacheTTL) * time.Millisecond
+	s.cacheStaleTTL = time.Duration(cfg.CacheStaleTTL) * time.Millisecond
+	s.maxRetries = cfg.MaxRetries
+	s.retryBaseDelay = time.Duration(cfg.RetryBaseDelay) * time.Millisecond
+
+	s.mu.Unlock()
+
+	// Closed in the background, after giving any request that grabbed
+	// oldCache just before the swap time to finish with it: a Redis
+	// teardown talks to the network and must not block the caller (the
+	// SIGHUP handling loop in main), and closing immediately could pull
+	// the connection out from under an in-flight lookup/push.
+	go func() {
+		time.Sleep(s.requestTimeout())
+
+		if err := oldCache.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *server) log() *zap.SugaredLogger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.logger
+}
+
+// matchRoute returns the route configured for path, or nil if none of
+// the configured path prefixes match.
+func (s *server) matchRoute(path string) *route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return matchRoute(s.routes, path)
+}
+
+func (s *server) requestTimeout() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.reqTimeout
+}
+
+func (s *server) isDebug() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.debug
+}
+
+// cacheTTLs returns the fresh and stale-while-revalidate windows.
+func (s *server) cacheTTLs() (time.Duration, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cacheTTL, s.cacheStaleTTL
+}
+
+// retryPolicy returns the shared upstream client and the retry budget
+// requests are retried with.
+func (s *server) retryPolicy() (*http.Client, int, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.httpClient, s.maxRetries, s.retryBaseDelay
+}
+
 func (s *server) addJSONContentType(w http.ResponseWriter) {
 	w.Header().Add(contentType, applicationJSON)
 }
 
-func (s *server) handler(ctx context.Context, logger *zap.SugaredLogger,
-	url string, done chan struct{}, w http.ResponseWriter, r *http.Request) {
-	defer func() {
-		select {
-		case done <- struct{}{}:
-		default:
+// fetch performs the upstream request for url, coalescing concurrent
+// callers through s.group so that only one of them actually hits the
+// target service. The shared call runs on a context detached from any
+// single caller's request, bounded by the configured request timeout,
+// so one client giving up doesn't cancel the fetch for the others.
+func (s *server) fetch(ctx context.Context, logger *zap.SugaredLogger,
+	rt *route, url string) (outputResp, error) {
+	ctx, span := s.tracer.Start(ctx, "upstream.fetch")
+	defer span.End()
+
+	// Only the caller that actually triggers the call has its logger
+	// used for request errors; waiters joining an in-flight fetch don't
+	// affect it and get the same shared result.
+	v, err, _ := s.group.Do(url, func() (interface{}, error) {
+		reqCtx, cancel := context.WithTimeout(
+			trace.ContextWithSpan(context.Background(), span),
+			s.requestTimeout())
+		defer cancel()
+
+		input := inputResp{}
+		if err := s.request(reqCtx, logger, rt.TargetAddr, url, &input); err != nil {
+			return nil, err
 		}
-	}()
 
-	// Receives a response from the target service.
-	input := inputResp{}
-	if err := s.request(ctx, logger, url,
-		s.reqTimeout*time.Millisecond, &input); err != nil {
-		_, _ = w.Write(internalErr)
-		return
-	}
+		if s.isDebug() {
+			logger.With(response, input).Debug(request)
+		}
 
-	logger = logger.With(response, input)
-
-	// Makes a result.
-	output := make(outputResp, len(input))
-	for k := range input {
-		// code -> slug
-		// country_name -> subtitle
-		// name -> title
-		output[k] = &outputItem{
-			Slug:     input[k].Code,
-			Subtitle: input[k].CountryName,
-			Title:    input[k].Name,
+		// Makes a result by applying the route's field map to each item.
+		output := make(outputResp, len(input))
+		for k := range input {
+			output[k] = mapItem(input[k], rt.FieldMap)
 		}
+
+		s.push(reqCtx, url, output)
+
+		return output, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	s.push(url, output)
-	s.response(logger, w, output)
+	return v.(outputResp), nil
+}
+
+// fetchResult carries a fetch outcome back to the handlerFunc goroutine
+// that is waiting on it.
+type fetchResult struct {
+	output outputResp
+	err    error
+}
+
+// handler runs the upstream fetch and reports its outcome on result,
+// which must be buffered so this goroutine never blocks on a send:
+// ctx's owner may already have given up and stopped receiving (e.g. the
+// shared fetch outlives a single caller's deadline), and handlerFunc
+// owns every write to the ResponseWriter, so handler must not touch it.
+func (s *server) handler(ctx context.Context, logger *zap.SugaredLogger,
+	rt *route, url string, result chan<- fetchResult) {
+	output, err := s.fetch(ctx, logger, rt, url)
+	result <- fetchResult{output: output, err: err}
 }
 
 func (s *server) response(logger *zap.SugaredLogger,
@@ -224,41 +482,95 @@ func (s *server) response(logger *zap.SugaredLogger,
 	}
 }
 
-func (s *server) pullAndResponse(key string, w http.ResponseWriter) bool {
-	val, found := s.cache.Get(key)
+// lookup reports the cache status of key: a fresh or stale entry, or a
+// miss if there's nothing cached or the stale window has also expired.
+func (s *server) lookup(ctx context.Context, key string) (*cacheEntry, string) {
+	s.mu.RLock()
+	cache := s.cache
+	s.mu.RUnlock()
+
+	entry, found, err := cache.Get(ctx, key)
+	if err != nil {
+		s.log().Error(err)
+		return nil, cacheMiss
+	}
+
 	if !found {
-		return false
+		return nil, cacheMiss
 	}
 
-	data, ok := val.(outputResp)
-	if !ok {
-		return false
+	_, staleTTL := s.cacheTTLs()
+
+	switch age := entry.age(); {
+	case age < entry.TTL:
+		return entry, cacheHit
+	case age < entry.TTL+staleTTL:
+		return entry, cacheStale
+	default:
+		return nil, cacheMiss
 	}
+}
+
+func (s *server) push(ctx context.Context, url string, data outputResp) {
+	s.mu.RLock()
+	cache := s.cache
+	s.mu.RUnlock()
 
-	s.response(s.logger, w, data)
+	ttl, staleTTL := s.cacheTTLs()
+
+	entry := &cacheEntry{
+		Data:      data,
+		FetchedAt: time.Now(),
+		TTL:       ttl,
+	}
 
-	return true
+	// Keeps the entry around for the stale window too, so a revalidate
+	// racing a natural expiry still has something to serve.
+	if err := cache.Set(ctx, url, entry, ttl+staleTTL); err != nil {
+		s.log().Error(err)
+	}
 }
 
-func (s *server) push(url string, data interface{}) {
-	s.cache.Add(url, data)
+// setCacheHeaders annotates the response with its cache status and age
+// so clients can reason about freshness.
+func (s *server) setCacheHeaders(w http.ResponseWriter, status string, age time.Duration) {
+	w.Header().Set(cacheHeader, status)
+	w.Header().Set(ageHeader, strconv.Itoa(int(age.Seconds())))
 }
 
 // handlerFunc processes requests.
 func (s *server) handlerFunc(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(),
-		time.Duration(s.reqTimeout)*time.Millisecond)
+	ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout())
 	defer cancel()
 
+	ctx, span := s.tracer.Start(ctx, "adviser.handle")
+	defer span.End()
+
+	s.metrics.inFlight.Inc()
+	defer s.metrics.inFlight.Dec()
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = rec
+	defer func() {
+		s.metrics.requestsTotal.WithLabelValues(rec.statusLabel()).Inc()
+	}()
+
+	rt := s.matchRoute(r.URL.Path)
+	if rt == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	// Adds "Content-Type" = "application/json".
 	s.addJSONContentType(w)
 
 	url := r.URL.String()
+	span.SetAttributes(attribute.String("http.url", url))
 
-	logger := s.logger.With(urlStr, url)
+	logger := s.log().With(urlStr, url)
 
 	// Logs the time to process a request.
-	if s.debug {
+	if s.isDebug() {
 		start := time.Now()
 		defer func() {
 			stop := time.Now()
@@ -269,18 +581,48 @@ func (s *server) handlerFunc(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Pull out of the cache.
-	if s.pullAndResponse(url, w) {
+	lookupCtx, cacheSpan := s.tracer.Start(ctx, "cache.lookup")
+	entry, status := s.lookup(lookupCtx, url)
+	cacheSpan.SetAttributes(attribute.String("cache.status", status))
+	cacheSpan.End()
+
+	s.metrics.observeCache(status)
+
+	if status != cacheMiss {
+		s.setCacheHeaders(w, status, entry.age())
+		s.response(logger, w, entry.Data)
+
+		if status == cacheStale {
+			// Serves the stale value immediately and refreshes the
+			// cache in the background; singleflight ensures this
+			// doesn't race with a concurrent miss for the same key.
+			go func() {
+				if _, err := s.fetch(context.Background(), logger, rt, url); err != nil {
+					logger.Error(err)
+				}
+			}()
+		}
+
 		return
 	}
 
-	done := make(chan struct{})
-	go s.handler(ctx, logger, url, done, w, r)
+	s.setCacheHeaders(w, cacheMiss, 0)
+
+	result := make(chan fetchResult, 1)
+	go s.handler(ctx, logger, rt, url, result)
 
 	select {
 	case <-ctx.Done():
 		w.WriteHeader(http.StatusGatewayTimeout)
 		_, _ = w.Write(internalErr)
-	case <-done:
+	case res := <-result:
+		if res.err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write(internalErr)
+			return
+		}
+
+		s.response(logger, w, res.output)
 	}
 }
 
@@ -321,12 +663,69 @@ func main() {
 
 	logger = logger.With("config", cfg)
 
-	cache, err := lru.New(cfg.CacheSize)
+	var tracerShutdown func(context.Context) error
+	if cfg.TracingEnabled {
+		shutdown, err := initTracer(context.Background(), cfg.TracingEndpoint)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		tracerShutdown = shutdown
+	}
+
+	cache, err := newCache(cfg)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	if err = newServer(logger, cache, cfg).listenAndServe(); err != nil {
-		logger.Fatal(err)
+	srv := newServer(logger, cache, cfg)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.listenAndServe(); err != nil &&
+			err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	go func() {
+		if err := srv.listenAndServeAdmin(); err != nil &&
+			err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		select {
+		case err := <-serveErr:
+			logger.Fatal(err)
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				if err := srv.reload(*fConfig); err != nil {
+					logger.Error(err)
+				}
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(),
+				srv.requestTimeout())
+
+			err := srv.Shutdown(ctx)
+			cancel()
+			if err != nil {
+				logger.Error(err)
+			}
+
+			if tracerShutdown != nil {
+				if err := tracerShutdown(context.Background()); err != nil {
+					logger.Error(err)
+				}
+			}
+
+			return
+		}
 	}
 }
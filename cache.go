@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheBackendLRU   = "lru"
+	cacheBackendRedis = "redis"
+)
+
+// Cache abstracts the storage backing the proxy's response cache, so a
+// single-process LRU and a shared Redis instance can be used
+// interchangeably.
+type Cache interface {
+	// Get returns the cached entry for key, if any.
+	Get(ctx context.Context, key string) (*cacheEntry, bool, error)
+	// Set stores entry under key, expiring it after ttl.
+	Set(ctx context.Context, key string, entry *cacheEntry, ttl time.Duration) error
+	// Close releases any resources held by the backend, e.g. a
+	// connection pool. The cache must not be used afterwards.
+	Close() error
+}
+
+// newCache builds the Cache backend selected by cfg.CacheBackend.
+func newCache(cfg *config) (Cache, error) {
+	switch cfg.CacheBackend {
+	case "", cacheBackendLRU:
+		return newLRUCache(cfg.CacheSize)
+	case cacheBackendRedis:
+		return newRedisCache(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
+
+// lruCache adapts hashicorp/golang-lru to the Cache interface. ttl is
+// ignored on Set: entries are evicted by size, not time, and staleness
+// is judged from cacheEntry.FetchedAt by the caller.
+type lruCache struct {
+	cache *lru.Cache
+}
+
+func newLRUCache(size int) (*lruCache, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lruCache{cache: c}, nil
+}
+
+func (c *lruCache) Get(_ context.Context, key string) (*cacheEntry, bool, error) {
+	val, found := c.cache.Get(key)
+	if !found {
+		return nil, false, nil
+	}
+
+	entry, ok := val.(*cacheEntry)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return entry, true, nil
+}
+
+func (c *lruCache) Set(_ context.Context, key string, entry *cacheEntry, _ time.Duration) error {
+	c.cache.Add(key, entry)
+	return nil
+}
+
+// Close is a no-op: lruCache holds no resources beyond the in-process map.
+func (c *lruCache) Close() error {
+	return nil
+}
+
+// redisCache adapts github.com/redis/go-redis/v9 to the Cache
+// interface, JSON-encoding entries so multiple adviser instances behind
+// a load balancer can share a warm cache.
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(url string) (*redisCache, error) {
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisCache{client: redis.NewClient(opt)}, nil
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) (*cacheEntry, bool, error) {
+	raw, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(raw, entry); err != nil {
+		return nil, false, err
+	}
+
+	return entry, true, nil
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, entry *cacheEntry, ttl time.Duration) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, key, raw, ttl).Err()
+}
+
+// Close closes the underlying Redis connection pool.
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
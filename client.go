@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// newHTTPClient builds the shared client used for all upstream fetches.
+// A single client (and its underlying transport) is reused across
+// requests so connections to upstream hosts are kept alive and pooled,
+// instead of a fresh client per call.
+func newHTTPClient(cfg *config) *http.Client {
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  false,
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// isRetryableStatus reports whether status is a transient upstream
+// failure worth retrying, as opposed to a definitive client or server
+// error.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableErr reports whether err looks like a transient network
+// failure (e.g. a reset or refused connection) rather than a context
+// cancellation or a permanent error, which aren't worth retrying.
+func isRetryableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := err.Error()
+
+	return strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "EOF")
+}
+
+// backoff returns the delay before retry attempt n (0-based): an
+// exponential base doubled per attempt, plus up to 50% jitter so
+// concurrent retries don't all land on the same instant.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << uint(attempt)
+
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.status); got != tt.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+// timeoutErr implements net.Error with a fixed Timeout() result.
+type timeoutErr struct{ timeout bool }
+
+func (e timeoutErr) Error() string   { return "timeout error" }
+func (e timeoutErr) Timeout() bool   { return e.timeout }
+func (e timeoutErr) Temporary() bool { return e.timeout }
+
+func TestIsRetryableErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "net timeout", err: timeoutErr{timeout: true}, want: true},
+		{name: "net non-timeout", err: timeoutErr{timeout: false}, want: false},
+		{name: "connection reset", err: errors.New("read: connection reset by peer"), want: true},
+		{name: "connection refused", err: errors.New("dial tcp: connection refused"), want: true},
+		{name: "eof", err: errors.New("unexpected EOF"), want: true},
+		{name: "unrelated error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableErr(tt.err); got != tt.want {
+				t.Errorf("isRetryableErr(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 0, min: base, max: base + base/2},
+		{attempt: 1, min: 2 * base, max: 2*base + base},
+		{attempt: 2, min: 4 * base, max: 4*base + 2*base},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := backoff(base, tt.attempt)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("backoff(%v, %d) = %v, want in [%v, %v]",
+					base, tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	reasonTimeout = "timeout"
+	reasonStatus  = "non-200"
+	reasonDecode  = "decode"
+	reasonNetwork = "network"
+)
+
+// metrics holds the Prometheus collectors exposed on the admin endpoint.
+type metrics struct {
+	requestsTotal   *prometheus.CounterVec
+	upstreamLatency prometheus.Histogram
+	cacheHits       prometheus.Counter
+	cacheStaleHits  prometheus.Counter
+	cacheMisses     prometheus.Counter
+	inFlight        prometheus.Gauge
+	upstreamErrors  *prometheus.CounterVec
+}
+
+// newMetrics registers the proxy's collectors against a dedicated
+// registry, so the admin endpoint only ever exposes adviser's own
+// metrics.
+func newMetrics() (*metrics, *prometheus.Registry) {
+	reg := prometheus.NewRegistry()
+	factory := promauto.With(reg)
+
+	return &metrics{
+		requestsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "adviser_requests_total",
+			Help: "Total number of proxied requests by response status.",
+		}, []string{"status"}),
+		upstreamLatency: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "adviser_upstream_latency_seconds",
+			Help:    "Latency of upstream fetches.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		cacheHits: factory.NewCounter(prometheus.CounterOpts{
+			Name: "adviser_cache_hits_total",
+			Help: "Number of requests served from a fresh cache entry.",
+		}),
+		cacheStaleHits: factory.NewCounter(prometheus.CounterOpts{
+			Name: "adviser_cache_stale_total",
+			Help: "Number of requests served from a stale cache entry.",
+		}),
+		cacheMisses: factory.NewCounter(prometheus.CounterOpts{
+			Name: "adviser_cache_misses_total",
+			Help: "Number of requests that found nothing usable in the cache.",
+		}),
+		inFlight: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "adviser_in_flight_requests",
+			Help: "Number of requests currently being handled.",
+		}),
+		upstreamErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "adviser_upstream_errors_total",
+			Help: "Number of failed upstream fetches by reason.",
+		}, []string{"reason"}),
+	}, reg
+}
+
+// observeCache records the outcome of a cache lookup.
+func (m *metrics) observeCache(status string) {
+	switch status {
+	case cacheHit:
+		m.cacheHits.Inc()
+	case cacheStale:
+		m.cacheStaleHits.Inc()
+	default:
+		m.cacheMisses.Inc()
+	}
+}
+
+// observeUpstreamError classifies and records a failed upstream fetch.
+func (m *metrics) observeUpstreamError(timedOut bool, reason string) {
+	if timedOut {
+		m.upstreamErrors.WithLabelValues(reasonTimeout).Inc()
+		return
+	}
+
+	m.upstreamErrors.WithLabelValues(reason).Inc()
+}
+
+// adminServer returns an http.Server exposing Prometheus metrics on
+// addr, independent of the main proxy listener.
+func adminServer(addr string, reg *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg,
+		promhttp.HandlerOpts{Registry: reg}))
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status
+// code written, for metrics purposes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) statusLabel() string {
+	return strconv.Itoa(r.status)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "strings"
+
+// route maps requests under PathPrefix to a target service, describing
+// how to transform each upstream item into an output item: FieldMap
+// maps an output field name to a dotted JSON path into the upstream
+// item (e.g. "code", "cases.ru", "coordinates.lat").
+type route struct {
+	PathPrefix string            `json:"path_prefix"`
+	TargetAddr string            `json:"target_addr"`
+	FieldMap   map[string]string `json:"field_map"`
+}
+
+// matchRoute returns the most specific route whose PathPrefix prefixes
+// path, or nil if none matches.
+func matchRoute(routes []*route, path string) *route {
+	var best *route
+	for _, rt := range routes {
+		if !strings.HasPrefix(path, rt.PathPrefix) {
+			continue
+		}
+
+		if best == nil || len(rt.PathPrefix) > len(best.PathPrefix) {
+			best = rt
+		}
+	}
+
+	return best
+}
+
+// mapItem builds an output item from raw by resolving each configured
+// field against it.
+func mapItem(raw map[string]interface{}, fieldMap map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(fieldMap))
+	for outField, path := range fieldMap {
+		out[outField] = lookupPath(raw, path)
+	}
+
+	return out
+}
+
+// lookupPath resolves a dotted path into a nested map, returning nil if
+// any segment is missing or isn't itself a map.
+func lookupPath(item map[string]interface{}, path string) interface{} {
+	cur := interface{}(item)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		cur = m[part]
+	}
+
+	return cur
+}
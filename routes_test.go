@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchRoute(t *testing.T) {
+	routes := []*route{
+		{PathPrefix: "/"},
+		{PathPrefix: "/places"},
+		{PathPrefix: "/places/v2"},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "root only", path: "/foo", want: "/"},
+		{name: "exact prefix beats root", path: "/places", want: "/places"},
+		{name: "longest prefix wins", path: "/places/v2/123", want: "/places/v2"},
+		{name: "no routes match", path: "nope", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchRoute(routes, tt.path)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("matchRoute(%q) = %v, want nil", tt.path, got)
+				}
+				return
+			}
+
+			if got == nil || got.PathPrefix != tt.want {
+				t.Fatalf("matchRoute(%q) = %v, want PathPrefix %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	item := map[string]interface{}{
+		"code": "LED",
+		"cases": map[string]interface{}{
+			"ru": "Санкт-Петербург",
+		},
+		"coordinates": map[string]interface{}{
+			"lat": 59.8,
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want interface{}
+	}{
+		{name: "top-level field", path: "code", want: "LED"},
+		{name: "nested field", path: "cases.ru", want: "Санкт-Петербург"},
+		{name: "nested numeric field", path: "coordinates.lat", want: 59.8},
+		{name: "missing top-level field", path: "missing", want: nil},
+		{name: "missing nested field", path: "cases.en", want: nil},
+		{name: "path into a non-map value", path: "code.sub", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lookupPath(item, tt.path)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("lookupPath(item, %q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapItem(t *testing.T) {
+	raw := map[string]interface{}{
+		"code":         "LED",
+		"name":         "Saint Petersburg",
+		"country_name": "Russia",
+	}
+	fieldMap := map[string]string{
+		"slug":     "code",
+		"title":    "name",
+		"subtitle": "country_name",
+	}
+
+	want := map[string]interface{}{
+		"slug":     "LED",
+		"title":    "Saint Petersburg",
+		"subtitle": "Russia",
+	}
+
+	got := mapItem(raw, fieldMap)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mapItem() = %v, want %v", got, want)
+	}
+}
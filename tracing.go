@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies adviser's spans in the tracing backend.
+const tracerName = "adviser"
+
+// initTracer wires up an OTLP/gRPC exporter against endpoint and
+// installs it as the global tracer provider. The returned shutdown
+// func must be called on exit to flush any buffered spans.
+func initTracer(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", tracerName))
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// tracer returns adviser's tracer. When tracing isn't enabled, the
+// global tracer provider is the OTel default no-op implementation, so
+// callers don't need to branch on whether tracing is configured.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}